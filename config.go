@@ -0,0 +1,234 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package swan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigFormat selects the serialization a ConfigHandler reads or writes.
+type ConfigFormat int
+
+const (
+	ConfigFormatJSON ConfigFormat = iota
+	ConfigFormatYAML
+)
+
+// ConfigHandler lets a long-running SWAN node read, watch and safely
+// rewrite its Configuration without a restart. configHandler is the only
+// implementation; it is exposed as an interface so the handlers in this
+// package depend on the read-modify-write contract rather than the
+// fsnotify-backed detail behind it.
+type ConfigHandler interface {
+
+	// Marshal serializes the current configuration in the format given.
+	Marshal(format ConfigFormat) ([]byte, error)
+
+	// Unmarshal replaces the current configuration with data decoded in
+	// the format given.
+	Unmarshal(format ConfigFormat, data []byte) error
+
+	// Fingerprint returns a hash of the current configuration, used by
+	// DoLockedAction and handlerConfig to detect concurrent changes.
+	Fingerprint() string
+
+	// DoLockedAction runs cb with exclusive access to the configuration,
+	// but only if fp still matches Fingerprint(). This stops two
+	// concurrent read-modify-write cycles from silently clobbering one
+	// another. cb's changes are only kept if it returns a nil error.
+	DoLockedAction(fp string, cb func(*Configuration) error) error
+
+	// Get returns the current configuration. Callers must not modify the
+	// value returned; changes must go through DoLockedAction.
+	Get() *Configuration
+}
+
+// configHandler is the fsnotify-backed ConfigHandler used by AddHandlers.
+// It keeps the live Configuration behind an RWMutex so handlers can read
+// it on every request while watchConfigFile swaps in a new value when the
+// settings file changes on disk.
+type configHandler struct {
+	mu     sync.RWMutex
+	config *Configuration
+
+	// settingsFile and format are set by watchConfigFile once it knows
+	// where the configuration lives on disk, so DoLockedAction can write
+	// its changes back there. They are empty for a configHandler created
+	// directly (e.g. in tests), which simply disables persistence.
+	settingsFile string
+	format       ConfigFormat
+}
+
+func newConfigHandler(c *Configuration) *configHandler {
+	return &configHandler{config: c}
+}
+
+func (h *configHandler) Get() *Configuration {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.config
+}
+
+func (h *configHandler) Marshal(format ConfigFormat) ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return marshalConfig(h.config, format)
+}
+
+func (h *configHandler) Unmarshal(format ConfigFormat, data []byte) error {
+	c := &Configuration{}
+	if err := unmarshalConfig(data, format, c); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.config = c
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *configHandler) Fingerprint() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return fingerprint(h.config)
+}
+
+func (h *configHandler) DoLockedAction(fp string, cb func(*Configuration) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if fingerprint(h.config) != fp {
+		return fmt.Errorf(
+			"configuration changed since fingerprint '%s' was read", fp)
+	}
+	n := *h.config
+	if err := cb(&n); err != nil {
+		return err
+	}
+	h.config = &n
+	if h.settingsFile != "" {
+		if err := h.persistLocked(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persistLocked writes the current configuration back to settingsFile, so a
+// change made through DoLockedAction - such as handlerConfig applying an API
+// patch - survives a restart instead of only living in memory until the
+// next unrelated disk write lets watchConfigFile clobber it with the stale
+// file contents. Callers must hold h.mu.
+func (h *configHandler) persistLocked() error {
+	b, err := marshalConfig(h.config, h.format)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(h.settingsFile, b, 0o600)
+}
+
+func fingerprint(c *Configuration) string {
+	b, err := marshalConfig(c, ConfigFormatJSON)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func marshalConfig(c *Configuration, format ConfigFormat) ([]byte, error) {
+	if format == ConfigFormatYAML {
+		return yaml.Marshal(c)
+	}
+	return json.Marshal(c)
+}
+
+func unmarshalConfig(data []byte, format ConfigFormat, c *Configuration) error {
+	if format == ConfigFormatYAML {
+		return yaml.Unmarshal(data, c)
+	}
+	return json.Unmarshal(data, c)
+}
+
+// watchConfigFile reloads settingsFile into s.configHandler whenever it
+// changes on disk, so an operator can rotate AccessKey, adjust Timeout,
+// toggle Debug or add/remove trusted OWID creators without restarting the
+// node. It runs until the process exits; a bad edit that fails to parse is
+// left in place on disk without being applied, rather than crashing the
+// watcher. It also tells s.configHandler where to persist changes made
+// through DoLockedAction (see handlerConfig), so an API patch isn't lost on
+// restart.
+func watchConfigFile(s *services, settingsFile string) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch settingsFile's parent directory rather than the file itself. A
+	// watch held on the file's own inode doesn't survive an atomic save
+	// (write-temp-then-rename) or the "..data" symlink swap Kubernetes uses
+	// to publish a new ConfigMap revision: both replace the directory entry
+	// rather than writing through the inode fsnotify is watching, which
+	// arrives as Remove/Rename against the old name - not Write - and
+	// silently ends the watch.
+	if err := w.Add(filepath.Dir(settingsFile)); err != nil {
+		w.Close()
+		return err
+	}
+	format := ConfigFormatJSON
+	if ext := filepath.Ext(settingsFile); ext == ".yaml" || ext == ".yml" {
+		format = ConfigFormatYAML
+	}
+	if ch, ok := s.configHandler.(*configHandler); ok {
+		ch.mu.Lock()
+		ch.settingsFile = settingsFile
+		ch.format = format
+		ch.mu.Unlock()
+	}
+	go func() {
+		for {
+			select {
+			case _, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				// Re-read settingsFile on every directory event rather
+				// than filtering by which entry changed: for an atomic
+				// save or a ConfigMap symlink swap, the entry fsnotify
+				// reports usually isn't settingsFile itself, only what it
+				// currently resolves to. ioutil.ReadFile follows that
+				// resolution for us.
+				data, err := ioutil.ReadFile(settingsFile)
+				if err != nil {
+					continue
+				}
+				s.configHandler.Unmarshal(format, data)
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,300 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package swan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"swift"
+
+	"swan/swanpb"
+)
+
+// hostMetadataKey is the metadata key a DecodeAsJSON caller must set
+// explicitly to identify the SWAN domain it is calling on behalf of.
+// gRPC servers never see the ":authority" pseudo-header in incoming
+// metadata (grpc-go strips HTTP/2 reserved headers before handlers run),
+// so the host has to travel as an ordinary metadata entry instead.
+const hostMetadataKey = "x-swan-host"
+
+// accessKeyMetadataKey is the metadata key a gRPC caller must set to the
+// access key an HTTP caller would otherwise send as the "accessKey" query
+// parameter or X-Swan-Access-Key header (see the operator's registerSwift
+// for the header form). s.access.GetAllowed reads it back off the context
+// under contextAccessKey, the same way it reads a caller-presented key off
+// an HTTP request.
+const accessKeyMetadataKey = "x-swan-access-key"
+
+type contextKey string
+
+// contextAccessKey is the context key the access key extracted from gRPC
+// metadata is stored under before s.access.GetAllowed is called, so the
+// interceptors below present a credential the same way the HTTP handlers'
+// getAccessAllowed already does for every other transport.
+const contextAccessKey contextKey = "swan-access-key"
+
+// withAccessKey copies the caller-presented access key from incoming gRPC
+// metadata onto ctx, mirroring requestHost's extraction of the SWAN host
+// below. Without this, accessInterceptor and accessStreamInterceptor called
+// s.access.GetAllowed with no credential for it to check at all.
+func withAccessKey(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if v := md.Get(accessKeyMetadataKey); len(v) > 0 {
+		return context.WithValue(ctx, contextAccessKey, v[0])
+	}
+	return ctx
+}
+
+// accessKeyServerStream wraps a grpc.ServerStream so that stream.Context()
+// returns the context accessStreamInterceptor already attached the access
+// key to, rather than the bare context ss was constructed with. Handlers
+// such as DecodeAsJSON call stream.Context() directly (to resolve the SWAN
+// host via requestHost), so without this wrapper they would see a context
+// with no access key on it even though the interceptor checked one.
+type accessKeyServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a *accessKeyServerStream) Context() context.Context {
+	return a.ctx
+}
+
+// grpcServer implements swanpb.SwanServiceServer on top of the same
+// services used by the HTTP handlers in handlers.go, handlerFetch.go and
+// handlerDecodeAsJSON.go, so the gRPC transport shares business logic and
+// configuration with the HTTP one rather than duplicating it.
+type grpcServer struct {
+	swanpb.UnimplementedSwanServiceServer
+	s *services
+}
+
+// AddGRPCHandlers starts a gRPC server on the listener provided, exposing
+// the same SWAN operations as AddHandlers over HTTP. It reuses the
+// services created for the HTTP transport so that state such as the
+// resolved access node is shared between the two.
+func AddGRPCHandlers(lis net.Listener, s *services) (*grpc.Server, error) {
+	g := grpc.NewServer(
+		grpc.UnaryInterceptor(accessInterceptor(s)),
+		grpc.StreamInterceptor(accessStreamInterceptor(s)))
+	swanpb.RegisterSwanServiceServer(g, &grpcServer{s: s})
+	go func() {
+		// Errors from Serve are not actionable here; the caller is
+		// expected to stop the server via GracefulStop on shutdown.
+		_ = g.Serve(lis)
+	}()
+	return g, nil
+}
+
+// accessInterceptor checks the caller can access the SWAN network using the
+// same Access implementation as the HTTP handlers' getAccessAllowed, before
+// any unary RPC is invoked.
+func accessInterceptor(s *services) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = withAccessKey(ctx)
+		if !s.access.GetAllowed(ctx) {
+			return nil, status.Error(codes.Unauthenticated, "Not authorized")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// accessStreamInterceptor is the streaming equivalent of accessInterceptor.
+// DecodeAsJSON is bidirectional-streaming, so it is never routed through a
+// grpc.UnaryServerInterceptor; without this, the only RPC that decrypts
+// SWAN blobs would run with no access control at all.
+func accessStreamInterceptor(s *services) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx := withAccessKey(ss.Context())
+		if !s.access.GetAllowed(ctx) {
+			return status.Error(codes.Unauthenticated, "Not authorized")
+		}
+		return handler(srv, &accessKeyServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func (g *grpcServer) Fetch(
+	ctx context.Context,
+	req *swanpb.FetchRequest) (*swanpb.FetchResponse, error) {
+	q, err := paramsToValues(req.Params)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	u, err := createStorageOperationURL(g.s, &q, fetchQueryDefaults)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &swanpb.FetchResponse{Url: u}, nil
+}
+
+func (g *grpcServer) Update(
+	ctx context.Context,
+	req *swanpb.UpdateRequest) (*swanpb.UpdateResponse, error) {
+	q, err := paramsToValues(req.Params)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	u, err := createStorageOperationURL(g.s, &q, func(*url.Values) {})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &swanpb.UpdateResponse{Url: u}, nil
+}
+
+// CreateOfferID shares handlerCreateOfferID's exact logic rather than
+// reimplementing or guessing at it: unlike Fetch and Update, there is no
+// createStorageOperationURL-style helper factored out of it to call
+// directly, so this drives the registered http.HandlerFunc itself through
+// an in-process request/response pair, the same way net/http would for a
+// real GET, and reports back whatever it wrote.
+func (g *grpcServer) CreateOfferID(
+	ctx context.Context,
+	req *swanpb.CreateOfferIDRequest) (*swanpb.CreateOfferIDResponse, error) {
+	q, err := paramsToValues(req.Params)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if ak, ok := ctx.Value(contextAccessKey).(string); ok {
+		q.Set("accessKey", ak)
+	}
+
+	rec := callHandler(handlerCreateOfferID(g.s), q)
+	body := strings.TrimSpace(rec.Body.String())
+	if rec.Code != http.StatusOK {
+		return nil, status.Error(codeFromHTTPStatus(rec.Code), body)
+	}
+	return &swanpb.CreateOfferIDResponse{OfferId: body}, nil
+}
+
+// callHandler drives h with an in-process GET carrying q as its query
+// string, so a gRPC RPC can reuse an existing http.HandlerFunc verbatim
+// instead of duplicating its logic. The access key, when present in q, is
+// set as both the query parameter and the X-Swan-Access-Key header so the
+// bridge works whichever of the two h's getAccessAllowed checks.
+func callHandler(h http.HandlerFunc, q url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil)
+	if ak := q.Get("accessKey"); ak != "" {
+		r.Header.Set("X-Swan-Access-Key", ak)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, r)
+	return rec
+}
+
+// codeFromHTTPStatus maps the status an HTTP handler wrote to the nearest
+// gRPC status code, so a bridged handler's errors come back as something
+// more specific than codes.Internal for the common cases.
+func codeFromHTTPStatus(code int) codes.Code {
+	switch code {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return codes.Unauthenticated
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	default:
+		return codes.Internal
+	}
+}
+
+// DecodeAsJSON reads encrypted SWAN blobs from the stream and writes back
+// the decoded, OWID-signed results one at a time, matching the semantics
+// of handlerDecodeAsJSON but without waiting for a whole batch to finish
+// decoding before any results are available to the caller.
+func (g *grpcServer) DecodeAsJSON(stream swanpb.SwanService_DecodeAsJSONServer) error {
+	ctx := stream.Context()
+	host := requestHost(ctx)
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+
+		in, err := decrypt(g.s, req.Data)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		results, err := swift.DecodeResults(in)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		if err := signResultsAsOWIDs(g.s, host, results); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		for _, p := range results.Values {
+			if err := stream.Send(&swanpb.DecodeAsJSONResponse{
+				Key:     p.Key,
+				Value:   p.Value,
+				Expires: p.Expires.Unix(),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func paramsToValues(m map[string]string) (url.Values, error) {
+	q := url.Values{}
+	for k, v := range m {
+		q.Set(k, v)
+	}
+	return q, nil
+}
+
+// requestHost returns the SWAN domain the caller is calling on behalf of,
+// taken from the hostMetadataKey entry the client is required to set
+// explicitly. The ":authority" pseudo-header is not usable here: grpc-go
+// strips HTTP/2 reserved headers from incoming metadata before a handler
+// ever sees it, so relying on it left every call resolving to an empty
+// host and failing OWID creator lookup.
+func requestHost(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(hostMetadataKey); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
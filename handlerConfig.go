@@ -0,0 +1,115 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package swan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// handlerConfig implements POST /swan/api/v1/config. The caller supplies
+// the fingerprint they last read the configuration under as the "fp" query
+// parameter, together with either a full Configuration document as the
+// request body, or - when the "path" query parameter is set - a raw JSON
+// value to write at that dotted field path. It returns the resulting
+// fingerprint so a caller can chain further patches without racing another
+// writer. The change is also written back to settingsFile by
+// configHandler.DoLockedAction, so it survives a restart and the next
+// watchConfigFile reload sees the same value rather than clobbering it.
+func handlerConfig(s *services) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		// Check caller can access
+		if s.getAccessAllowed(w, r) == false {
+			returnAPIError(s, w, r,
+				errors.New("Not authorized"),
+				http.StatusUnauthorized)
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			returnAPIError(s, w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		fp := r.URL.Query().Get("fp")
+		path := r.URL.Query().Get("path")
+		err = s.configHandler.DoLockedAction(fp, func(c *Configuration) error {
+			if path == "" {
+				return unmarshalConfig(body, ConfigFormatJSON, c)
+			}
+			return setConfigPath(c, path, body)
+		})
+		if err != nil {
+			returnRequestError(s, w, r, err, http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		json.NewEncoder(w).Encode(map[string]string{
+			"fingerprint": s.configHandler.Fingerprint(),
+		})
+	}
+}
+
+// setConfigPath sets the single field of c addressed by a dot-separated
+// path, such as "Observability.Enabled", to the raw JSON value in data. It
+// round-trips c through JSON so the patch goes through the same
+// (un)marshalling as the rest of Configuration, rather than needing a
+// reflect-based setter or a case per field.
+func setConfigPath(c *Configuration, path string, data []byte) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	segments := strings.Split(path, ".")
+	cur := m
+	for i, seg := range segments {
+		if i == len(segments)-1 {
+			cur[seg] = v
+			break
+		}
+		next, ok := cur[seg].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf(
+				"'%s' is not an object", strings.Join(segments[:i+1], "."))
+		}
+		cur = next
+	}
+
+	patched, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(patched, c)
+}
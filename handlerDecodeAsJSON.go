@@ -17,17 +17,36 @@
 package swan
 
 import (
-	"crypto/sha1"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"swift"
 	"time"
+
+	"golang.org/x/net/idna"
 )
 
+// sidContext domain-separates the SID HMAC from any other use of the same
+// pepper key elsewhere in the SWAN network.
+const sidContext = "swan-sid-v1"
+
+// SIDPepper is one entry in Configuration.SIDPeppers: a key used to HMAC
+// email addresses into SIDs, and the ID that createSID stamps into the
+// result so a SID can be traced back to the pepper it was created under.
+// Keeping old peppers listed here after CurrentSIDPepperID moves on lets
+// SIDs minted before a rotation keep verifying until the rollover window
+// closes.
+type SIDPepper struct {
+	ID  byte
+	Key []byte
+}
+
 func handlerDecodeAsJSON(s *services) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -35,7 +54,7 @@ func handlerDecodeAsJSON(s *services) http.HandlerFunc {
 
 		// Check caller can access
 		if s.getAccessAllowed(w, r) == false {
-			returnAPIError(&s.config, w,
+			returnAPIError(s, w, r,
 				errors.New("Not authorized"),
 				http.StatusUnauthorized)
 			return
@@ -44,78 +63,114 @@ func handlerDecodeAsJSON(s *services) http.HandlerFunc {
 		// Get the form values from the input request.
 		err := r.ParseForm()
 		if err != nil {
-			returnAPIError(&s.config, w, err, http.StatusInternalServerError)
+			returnAPIError(s, w, r, err, http.StatusInternalServerError)
 			return
 		}
 
 		// Decrypt the string with the access node.
 		in, err := decrypt(s, r.Form.Get("data"))
 		if err != nil {
-			returnAPIError(&s.config, w, err, http.StatusUnprocessableEntity)
+			returnAPIError(s, w, r, err, http.StatusUnprocessableEntity)
 			return
 		}
 
 		// Get the results.
 		results, err = swift.DecodeResults(in)
 		if err != nil {
-			returnAPIError(&s.config, w, err, http.StatusUnprocessableEntity)
+			returnAPIError(s, w, r, err, http.StatusUnprocessableEntity)
 			return
 		}
 
-		// Change the values to OWIDs.
-		for _, p := range results.Values {
-			if p.Key == "email" {
-				p.Key = "sid"
-				p.Value, err = encodeAsOWID(s, r, createSID(p.Value))
-			} else {
-				p.Value, err = encodeAsOWID(s, r, []byte(p.Value))
-			}
-			if err != nil {
-				returnAPIError(&s.config, w, err, http.StatusInternalServerError)
-				return
-			}
-		}
-
-		// Modify the expiry time.
-		for _, i := range results.Values {
-			i.Expires = time.Now().UTC().Add(time.Second * s.config.Timeout)
+		// Change the values to OWIDs and re-stamp their expiry.
+		if err := signResultsAsOWIDs(s, r.Host, results); err != nil {
+			returnAPIError(s, w, r, err, http.StatusInternalServerError)
+			return
 		}
 
 		// Return the results as a JSON string.
 		if err := json.NewEncoder(w).Encode(results.Values); err != nil {
-			returnAPIError(&s.config, w, err, http.StatusUnprocessableEntity)
+			returnAPIError(s, w, r, err, http.StatusUnprocessableEntity)
 		}
 	}
 }
 
 func decrypt(s *services, d string) ([]byte, error) {
 
+	c := s.getConfig()
+
 	// Combine it with the access node to decrypt the result.
-	u, err := url.Parse(s.config.Scheme + "://" + s.accessNode)
+	u, err := url.Parse(c.Scheme + "://" + s.accessNode)
 	if err != nil {
 		return nil, err
 	}
 	u.Path = "/swift/api/v1/decrypt"
 	q := u.Query()
 	q.Set("data", d)
-	q.Set("accessKey", s.config.AccessKey)
+	q.Set("accessKey", c.AccessKey)
 	u.RawQuery = q.Encode()
 
 	// Call the URL and unpack the results if they're available.
 	res, err := http.Get(u.String())
 	if err != nil {
+		s.countDecryptFailure()
 		return nil, err
 	}
 	if res.StatusCode != http.StatusOK {
+		s.countDecryptFailure()
 		return nil, newResponseError(u.String(), res)
 	}
 	return ioutil.ReadAll(res.Body)
 }
 
-func encodeAsOWID(s *services, r *http.Request, v []byte) (string, error) {
+// countDecryptFailure records a failed call to the access node's decrypt
+// endpoint against the swan_decrypt_failures_total counter, when
+// observability is enabled.
+func (s *services) countDecryptFailure() {
+	if s.metrics != nil {
+		s.metrics.decryptFailures.Inc()
+	}
+}
+
+// countOWIDSignFailure records a failed OWID signing operation against the
+// swan_owid_sign_failures_total counter, when observability is enabled.
+func (s *services) countOWIDSignFailure() {
+	if s.metrics != nil {
+		s.metrics.owidSignFailures.Inc()
+	}
+}
+
+// signResultsAsOWIDs turns every decoded SWIFT value into an OWID-signed
+// string, turning "email" into a SID first, and re-stamps every value's
+// expiry to now + Configuration.Timeout. It is shared by handlerDecodeAsJSON
+// and the DecodeAsJSON RPC in grpc.go so both transports produce identical
+// results for the same encrypted input rather than drifting apart.
+func signResultsAsOWIDs(s *services, host string, results *swift.Results) error {
+	for _, p := range results.Values {
+		var err error
+		if p.Key == "email" {
+			p.Key = "sid"
+			p.Value, err = encodeAsOWID(s, host, createSID(s, p.Value))
+		} else {
+			p.Value, err = encodeAsOWID(s, host, []byte(p.Value))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, i := range results.Values {
+		i.Expires = time.Now().UTC().Add(time.Second * s.getConfig().Timeout)
+	}
+	return nil
+}
+
+// encodeAsOWID signs the value provided with the OWID creator registered
+// for host, which is the SWAN domain the caller connected to. It is shared
+// by handlerDecodeAsJSON and the DecodeAsJSON RPC in grpc.go, which derives
+// host from the gRPC request metadata rather than an *http.Request.
+func encodeAsOWID(s *services, host string, v []byte) (string, error) {
 
 	// Get the creator associated with this SWAN domain.
-	c, err := s.owid.GetCreator(r.Host)
+	c, err := s.owid.GetCreator(host)
 	if err != nil {
 		return "", err
 	}
@@ -123,24 +178,106 @@ func encodeAsOWID(s *services, r *http.Request, v []byte) (string, error) {
 		return "", fmt.Errorf(
 			"No creator for '%s'. Use http[s]://%s/owid/register to setup "+
 				"domain.",
-			r.Host,
-			r.Host)
+			host,
+			host)
 	}
 
 	// Create and sign the OWID.
 	o := c.CreateOWID(v)
 	err = c.Sign(o)
 	if err != nil {
+		s.countOWIDSignFailure()
 		return "", err
 	}
 
 	return o.AsBase64()
 }
 
-// TODO : What hashing algorithm do we want to use to turn email address into
-// hashes?
-func createSID(s string) []byte {
-	hasher := sha1.New()
-	hasher.Write([]byte(s))
-	return hasher.Sum(nil)
+// createSID turns an email address into a SID: an HMAC-SHA256 of the
+// normalized address keyed by the deployment's current SID pepper,
+// prefixed with the ID of the pepper used so that SIDs created under older
+// peppers can still be verified during a rotation. The result is always
+// 1+sha256.Size bytes, whichever pepper is active, so rotating peppers does
+// not change downstream OWID sizes.
+func createSID(s *services, email string) []byte {
+	id, key := currentSIDPepper(s)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sidContext))
+	mac.Write([]byte(normalizeEmail(email)))
+	return append([]byte{id}, mac.Sum(nil)...)
+}
+
+// verifySID reports whether sid was produced by createSID for email under
+// any of the configuration's active SID peppers, so a SID minted just
+// before a pepper rotation still verifies during the rollover window.
+func verifySID(s *services, email string, sid []byte) bool {
+	if len(sid) != 1+sha256.Size {
+		return false
+	}
+	key, ok := sidPepperByID(s, sid[0])
+	if !ok {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sidContext))
+	mac.Write([]byte(normalizeEmail(email)))
+	return hmac.Equal(sid[1:], mac.Sum(nil))
+}
+
+// normalizeEmail lower-cases and trims an email address, and IDN-normalizes
+// its domain part, so that addresses which are equivalent but differ only
+// in case, surrounding whitespace or Unicode form always produce the same
+// SID.
+func normalizeEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	if a, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = a
+	}
+	return local + "@" + domain
+}
+
+// currentSIDPepper returns the ID and key of the pepper that new SIDs
+// should be created with. SIDPeppers and CurrentSIDPepperID are read from
+// the deployment's Configuration so that an operator can rotate the pepper
+// without redeploying; if no peppers have been configured, a key is
+// derived from the network's existing AccessKey so a fresh deployment
+// still peppers its SIDs rather than hashing them unkeyed.
+func currentSIDPepper(s *services) (byte, []byte) {
+	id := s.getConfig().CurrentSIDPepperID
+	if key, ok := sidPepperByID(s, id); ok {
+		return id, key
+	}
+	return 0, fallbackSIDPepper(s)
+}
+
+// sidPepperByID looks up one of the deployment's active SID peppers by ID,
+// so a SID created under a key that has since been superseded can still be
+// verified while the old key remains listed in Configuration.
+func sidPepperByID(s *services, id byte) ([]byte, bool) {
+	for _, p := range s.getConfig().SIDPeppers {
+		if p.ID == id {
+			return p.Key, true
+		}
+	}
+	if id == 0 {
+		return fallbackSIDPepper(s), true
+	}
+	return nil, false
+}
+
+// fallbackSIDPepper derives a per-network pepper from the network's
+// AccessKey when no SID pepper has been configured explicitly, so that
+// existing deployments that have not set one up still get a keyed hash
+// rather than the unkeyed SHA-1 this replaces.
+func fallbackSIDPepper(s *services) []byte {
+	c := s.getConfig()
+	mac := hmac.New(sha256.New, []byte(c.AccessKey))
+	mac.Write([]byte("swan-sid-fallback-pepper"))
+	mac.Write([]byte(c.Network))
+	return mac.Sum(nil)
 }
@@ -0,0 +1,75 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package swan
+
+import "testing"
+
+// TestCreateSIDVerifySIDRoundTrip confirms that a SID minted under the
+// current pepper verifies, and - the point of the rotation support added
+// alongside createSID - that a SID minted before a pepper rotation still
+// verifies afterwards, as long as the old pepper is still listed.
+func TestCreateSIDVerifySIDRoundTrip(t *testing.T) {
+	s := &services{configHandler: newConfigHandler(&Configuration{
+		CurrentSIDPepperID: 1,
+		SIDPeppers: []SIDPepper{
+			{ID: 1, Key: []byte("first-pepper")},
+		},
+	})}
+
+	email := "  Some.User@Example.COM "
+	sid := createSID(s, email)
+
+	if !verifySID(s, email, sid) {
+		t.Fatal("SID did not verify under the pepper it was created with")
+	}
+	if verifySID(s, "someone.else@example.com", sid) {
+		t.Fatal("SID verified for the wrong email address")
+	}
+
+	// Rotate to a new pepper, keeping the old one listed for the rollover
+	// window.
+	if err := s.configHandler.DoLockedAction(s.configHandler.Fingerprint(), func(c *Configuration) error {
+		c.CurrentSIDPepperID = 2
+		c.SIDPeppers = append(c.SIDPeppers, SIDPepper{ID: 2, Key: []byte("second-pepper")})
+		return nil
+	}); err != nil {
+		t.Fatalf("rotation failed: %v", err)
+	}
+
+	if !verifySID(s, email, sid) {
+		t.Fatal("SID created under the old pepper no longer verifies during rollover")
+	}
+
+	newSID := createSID(s, email)
+	if newSID[0] != 2 {
+		t.Fatalf("expected new SIDs to be stamped with the current pepper ID 2, got %d", newSID[0])
+	}
+	if !verifySID(s, email, newSID) {
+		t.Fatal("SID created under the new pepper did not verify")
+	}
+}
+
+// TestNormalizeEmail confirms that addresses which are equivalent but
+// differ only in case or surrounding whitespace produce the same SID.
+func TestNormalizeEmail(t *testing.T) {
+	s := &services{configHandler: newConfigHandler(&Configuration{})}
+	a := createSID(s, "user@example.com")
+	b := createSID(s, "  User@Example.com  ")
+	if string(a) != string(b) {
+		t.Fatal("equivalent email addresses produced different SIDs")
+	}
+}
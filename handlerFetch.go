@@ -32,7 +32,7 @@ func handlerFetch(s *services) http.HandlerFunc {
 
 		// Check caller can access
 		if s.getAccessAllowed(w, r) == false {
-			returnAPIError(&s.config, w,
+			returnAPIError(s, w, r,
 				errors.New("Not authorized"),
 				http.StatusUnauthorized)
 			return
@@ -41,14 +41,14 @@ func handlerFetch(s *services) http.HandlerFunc {
 		// Get the form values from the input request.
 		err := r.ParseForm()
 		if err != nil {
-			returnAPIError(&s.config, w, err, http.StatusInternalServerError)
+			returnAPIError(s, w, r, err, http.StatusInternalServerError)
 			return
 		}
 
 		// Copy the incoming parameters into the outgoing ones.
 		q, err := url.ParseQuery(r.Form.Encode())
 		if err != nil {
-			returnAPIError(&s.config, w, err, http.StatusInternalServerError)
+			returnAPIError(s, w, r, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -56,17 +56,9 @@ func handlerFetch(s *services) http.HandlerFunc {
 		validateCommon(s, w, r, q)
 
 		// Create the URL with the parameters provided by the publisher.
-		u, err := createStorageOperationURL(
-			s,
-			&q,
-			func(q *url.Values) {
-				t := time.Now().UTC().AddDate(0, 3, 0).Format("2006-01-02")
-				q.Set(fmt.Sprintf("cbid<%s", t), uuid.New().String())
-				q.Set(fmt.Sprintf("email<%s", t), "")
-				q.Set(fmt.Sprintf("allow<%s", t), "")
-			})
+		u, err := createStorageOperationURL(s, &q, fetchQueryDefaults)
 		if err != nil {
-			returnAPIError(&s.config, w, err, http.StatusInternalServerError)
+			returnAPIError(s, w, r, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -77,33 +69,47 @@ func handlerFetch(s *services) http.HandlerFunc {
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b)))
 		_, err = w.Write(b)
 		if err != nil {
-			returnAPIError(&s.config, w, err, http.StatusInternalServerError)
+			returnAPIError(s, w, r, err, http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
+// fetchQueryDefaults adds the default SWAN values that a fresh fetch
+// operation needs - a new random cbid and empty email and allow values -
+// each keyed to a date three months in the future. It is
+// shared by handlerFetch and the equivalent Fetch RPC in grpc.go so both
+// transports start a storage operation the same way.
+func fetchQueryDefaults(q *url.Values) {
+	t := time.Now().UTC().AddDate(0, 3, 0).Format("2006-01-02")
+	q.Set(fmt.Sprintf("cbid<%s", t), uuid.New().String())
+	q.Set(fmt.Sprintf("email<%s", t), "")
+	q.Set(fmt.Sprintf("allow<%s", t), "")
+}
+
 func createStorageOperationURL(
 	s *services,
 	q *url.Values,
 	fn func(q *url.Values)) (string, error) {
 
+	c := s.getConfig()
+
 	// Check that an access node exists for SWAN. If not try to update the
 	// access node before erroring.
 	if s.accessNode == "" {
-		an, err := s.swift.GetAccessNode(s.config.Network)
+		an, err := s.swift.GetAccessNode(c.Network)
 		if err != nil && an == "" {
 			return "", fmt.Errorf("An access node has not been created for the"+
 				" '%s' network. Use http[s]://[domain]/swift/register to start"+
 				" the network.",
-				s.config.Network)
+				c.Network)
 		}
 		s.accessNode = an
 	}
 
 	// Build a new URL to request the first storage operation URL.
 	var u url.URL
-	u.Scheme = s.config.Scheme
+	u.Scheme = c.Scheme
 	u.Host = s.accessNode
 	u.Path = "/swift/api/v1/create"
 
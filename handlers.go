@@ -19,10 +19,15 @@ package swan
 import (
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"owid"
 	"strings"
 	"swift"
+
+	"google.golang.org/grpc"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // AddHandlers adds swift and owid end points configured from the JSON file
@@ -38,6 +43,53 @@ func AddHandlers(
 	// Create the new set of services.
 	s := newServices(settingsFile, swanAccess, swiftAccess, owidAccess)
 
+	// Watch the settings file so that config changes made on disk, or via
+	// the /swan/api/v1/config endpoint below, take effect on the next
+	// request without recreating the HTTP mux.
+	if err := watchConfigFile(s, settingsFile); err != nil {
+		return err
+	}
+
+	return addHTTPHandlers(s, swiftAccess, owidAccess, htmlTemplate, malformedHandler)
+}
+
+// AddHandlersAndGRPC does everything AddHandlers does for the HTTP
+// transport, and also starts a gRPC server on grpcListener exposing the
+// same SWAN operations, sharing a single set of services between the two
+// so that state such as the resolved access node is shared instead of
+// duplicated. This is the only exported way to obtain the *grpc.Server
+// handle needed to stop the gRPC transport cleanly on shutdown, since
+// services itself is not exported.
+func AddHandlersAndGRPC(
+	settingsFile string,
+	swanAccess Access,
+	swiftAccess swift.Access,
+	owidAccess owid.Access,
+	htmlTemplate string,
+	malformedHandler func(w http.ResponseWriter, r *http.Request),
+	grpcListener net.Listener) (*grpc.Server, error) {
+
+	s := newServices(settingsFile, swanAccess, swiftAccess, owidAccess)
+
+	if err := watchConfigFile(s, settingsFile); err != nil {
+		return nil, err
+	}
+	if err := addHTTPHandlers(s, swiftAccess, owidAccess, htmlTemplate, malformedHandler); err != nil {
+		return nil, err
+	}
+	return AddGRPCHandlers(grpcListener, s)
+}
+
+// addHTTPHandlers registers every SWAN, SWIFT and OWID HTTP handler against
+// s. It is split out of AddHandlers so AddHandlersAndGRPC can register the
+// same handlers against a services shared with a gRPC server.
+func addHTTPHandlers(
+	s *services,
+	swiftAccess swift.Access,
+	owidAccess owid.Access,
+	htmlTemplate string,
+	malformedHandler func(w http.ResponseWriter, r *http.Request)) error {
+
 	// Add the SWIFT handlers.
 	swift.AddHandlers(s.swift, malformedHandler)
 
@@ -45,18 +97,40 @@ func AddHandlers(
 	owid.AddHandlers(s.owid)
 
 	// Add the SWAN handlers.
-	http.HandleFunc("/swan/api/v1/fetch", handlerFetch(s))
-	http.HandleFunc("/swan/api/v1/update", handlerUpdate(s))
-	http.HandleFunc("/swan/api/v1/decode-as-json", handlerDecodeAsJSON(s))
-	http.HandleFunc("/swan/api/v1/create-offer-id", handlerCreateOfferID(s))
+	http.HandleFunc("/swan/api/v1/fetch", instrument(s, "fetch", handlerFetch(s)))
+	http.HandleFunc("/swan/api/v1/update", instrument(s, "update", handlerUpdate(s)))
+	http.HandleFunc("/swan/api/v1/decode-as-json", instrument(s, "decode-as-json", handlerDecodeAsJSON(s)))
+	http.HandleFunc("/swan/api/v1/create-offer-id", instrument(s, "create-offer-id", handlerCreateOfferID(s)))
 	h, err := handlerCapture(s, htmlTemplate)
 	if err != nil {
 		return err
 	}
-	http.HandleFunc("/swan/preferences/", h)
+	http.HandleFunc("/swan/preferences/", instrument(s, "preferences", h))
+	http.HandleFunc("/swan/api/v1/config", instrument(s, "config", handlerConfig(s)))
+
+	// /metrics is always registered, but defers to the live Configuration on
+	// every scrape rather than only at startup, so that an operator can
+	// turn Observability on or off through watchConfigFile or
+	// handlerConfig without restarting, exactly as instrument already does
+	// for request metrics.
+	http.HandleFunc("/metrics", handlerMetrics(s))
 	return nil
 }
 
+// handlerMetrics serves s.metricsRegistry's collectors for scraping while
+// Configuration.Observability is enabled, and 404s otherwise, so the
+// decision of whether /metrics is live can change on the next scrape
+// instead of being fixed at the time addHTTPHandlers ran.
+func handlerMetrics(s *services) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.getConfig().Observability.Enabled || s.metricsRegistry == nil {
+			http.NotFound(w, r)
+			return
+		}
+		promhttp.HandlerFor(s.metricsRegistry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
+
 func newResponseError(url string, resp *http.Response) error {
 	in, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -67,48 +141,63 @@ func newResponseError(url string, resp *http.Response) error {
 }
 
 func returnAPIError(
-	c *Configuration,
+	s *services,
 	w http.ResponseWriter,
+	r *http.Request,
 	err error,
 	code int) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	http.Error(w, err.Error(), code)
-	if c.Debug {
+	logRequestError(s, r, code, err)
+	if s.getConfig().Debug {
 		println(err.Error())
 	}
 }
 
 func returnRequestError(
-	c *Configuration,
+	s *services,
 	w http.ResponseWriter,
+	r *http.Request,
 	err error,
 	code int) {
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	if c.Debug {
+	debug := s.getConfig().Debug
+	if debug {
 		http.Error(w, err.Error(), code)
 	} else {
 		http.Error(w, "", code)
 	}
-	if c.Debug {
+	logRequestError(s, r, code, err)
+	if debug {
 		println(err.Error())
 	}
 }
 
-func returnServerError(c *Configuration, w http.ResponseWriter, err error) {
+func returnServerError(s *services, w http.ResponseWriter, r *http.Request, err error) {
 	w.Header().Set("Cache-Control", "no-cache")
-	if c.Debug {
+	debug := s.getConfig().Debug
+	if debug {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	} else {
 		http.Error(w, "", http.StatusInternalServerError)
 	}
-	if c.Debug {
+	logRequestError(s, r, http.StatusInternalServerError, err)
+	if debug {
 		println(err.Error())
 	}
 }
 
+// getConfig returns the live Configuration, dereferenced through
+// s.configHandler so that a config change delivered by watchConfigFile or
+// handlerConfig takes effect on the very next call, without recreating the
+// HTTP mux.
+func (s *services) getConfig() *Configuration {
+	return s.configHandler.Get()
+}
+
 // Removes white space from the HTML string provided whilst retaining valid
 // HTML.
 func removeHTMLWhiteSpace(h string) string {
@@ -0,0 +1,164 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package swan
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// Observability turns on the optional Prometheus metrics and structured
+// request logging added to the SWAN handlers. It defaults to disabled so
+// that deployments which have not configured it keep the plain
+// http.Error/println behaviour they had before.
+type Observability struct {
+	Enabled bool
+}
+
+// metrics holds the Prometheus collectors shared by every SWAN endpoint.
+// One is created per services, registered against that services'
+// metricsRegistry, so an operator embedding this package can scrape their
+// own registry rather than the global default.
+type metrics struct {
+	requests         *prometheus.CounterVec
+	errors           *prometheus.CounterVec
+	latency          *prometheus.HistogramVec
+	responseSize     *prometheus.HistogramVec
+	decryptFailures  prometheus.Counter
+	owidSignFailures prometheus.Counter
+}
+
+// newMetrics registers the SWAN collectors against reg and returns them.
+// It is called from newServices when Configuration.Observability.Enabled
+// is true.
+func newMetrics(reg prometheus.Registerer) *metrics {
+	f := promauto.With(reg)
+	return &metrics{
+		requests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "swan_requests_total",
+			Help: "Total number of requests handled, by endpoint.",
+		}, []string{"endpoint"}),
+		errors: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "swan_request_errors_total",
+			Help: "Total number of requests that returned an error, by endpoint and status class.",
+		}, []string{"endpoint", "class"}),
+		latency: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "swan_request_duration_seconds",
+			Help: "Request latency, by endpoint.",
+		}, []string{"endpoint"}),
+		responseSize: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "swan_response_size_bytes",
+			Help:    "Response size, by endpoint.",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{"endpoint"}),
+		decryptFailures: f.NewCounter(prometheus.CounterOpts{
+			Name: "swan_decrypt_failures_total",
+			Help: "Total number of failed decrypt calls to the access node.",
+		}),
+		owidSignFailures: f.NewCounter(prometheus.CounterOpts{
+			Name: "swan_owid_sign_failures_total",
+			Help: "Total number of failed OWID signing operations.",
+		}),
+	}
+}
+
+// instrument wraps h so each request against endpoint is counted, timed
+// and sized against s.metrics. The Configuration.Observability.Enabled
+// check happens on every request, not once when the handler is wrapped, so
+// that toggling it through watchConfigFile or handlerConfig takes effect on
+// the next request exactly like every other live Configuration field - see
+// getConfig. s.metrics is only non-nil once Observability has been enabled
+// at least once for this services (see newServices), so that guards the
+// same switch.
+func instrument(s *services, endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.getConfig().Observability.Enabled || s.metrics == nil {
+			h(w, r)
+			return
+		}
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h(sw, r)
+		s.metrics.requests.WithLabelValues(endpoint).Inc()
+		s.metrics.latency.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		s.metrics.responseSize.WithLabelValues(endpoint).Observe(float64(sw.size))
+		if sw.status >= http.StatusBadRequest {
+			s.metrics.errors.WithLabelValues(endpoint, statusClass(sw.status)).Inc()
+		}
+	}
+}
+
+// statusWriter records the status code and byte count written through an
+// http.ResponseWriter so instrument can report them once the wrapped
+// handler returns.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func statusClass(code int) string {
+	switch {
+	case code >= http.StatusInternalServerError:
+		return "5xx"
+	case code >= http.StatusBadRequest:
+		return "4xx"
+	default:
+		return "2xx"
+	}
+}
+
+// logRequestError emits a structured JSON log line for a failed request
+// via s.log. It is called from returnAPIError, returnRequestError and
+// returnServerError, whose Debug-gated println(err.Error()) calls still run
+// whether or not Observability is enabled. It is a no-op unless
+// Configuration.Observability is enabled.
+func logRequestError(s *services, r *http.Request, code int, err error) {
+	if !s.getConfig().Observability.Enabled || s.log == nil {
+		return
+	}
+	s.log.Error("swan request failed",
+		zap.String("requestID", r.Header.Get("X-Request-Id")),
+		zap.String("remoteIP", remoteIP(r)),
+		zap.String("endpoint", r.URL.Path),
+		zap.Int("status", code),
+		zap.Error(err))
+}
+
+// remoteIP prefers the X-Forwarded-For header set by a front-end proxy
+// over RemoteAddr, which would otherwise just be the proxy's address.
+func remoteIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Forwarded-For"); ip != "" {
+		return ip
+	}
+	return r.RemoteAddr
+}
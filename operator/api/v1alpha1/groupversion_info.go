@@ -0,0 +1,41 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+// Package v1alpha1 contains the SwanNetwork and SwanNode custom resource
+// definitions used by the operator in operator/controllers to provision
+// SWAN access nodes and OWID creators without the manual visits to
+// /swift/register and /owid/register that setting up a network otherwise
+// requires.
+// +kubebuilder:object:generate=true
+// +groupName=swan.51degrees.com
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the API group and version used for every resource in
+	// this package.
+	GroupVersion = schema.GroupVersion{Group: "swan.51degrees.com", Version: "v1alpha1"}
+
+	// SchemeBuilder registers the types in this package with a Scheme.
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the types in this package to a Scheme.
+	AddToScheme = SchemeBuilder.AddToScheme
+)
@@ -0,0 +1,88 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SwanNetworkSpec describes a SWAN network: the parameters that would
+// otherwise be handed to newServices via a settings file shared by hand
+// between every node operator.
+type SwanNetworkSpec struct {
+
+	// Network is the SWAN network name, matching Configuration.Network.
+	Network string `json:"network"`
+
+	// Scheme is either "http" or "https", matching Configuration.Scheme.
+	Scheme string `json:"scheme"`
+
+	// Domains lists the publisher domains that are members of this
+	// network. The reconcilers do not read it; each SwanNode registers an
+	// OWID creator for its own Host only.
+	Domains []string `json:"domains"`
+
+	// AccessSecretRef names a Kubernetes Secret in the same namespace
+	// holding the shared access key that would otherwise be set as
+	// Configuration.AccessKey by hand on every node.
+	AccessSecretRef string `json:"accessSecretRef"`
+
+	// Timeout is the SID/OWID expiry in seconds, matching
+	// Configuration.Timeout.
+	Timeout int64 `json:"timeout"`
+}
+
+// SwanNetworkStatus reports the network's resolved access node. SwanNode
+// reconciliations pass it to each node's pod in the SWAN_ACCESS_NODE
+// environment variable.
+type SwanNetworkStatus struct {
+
+	// AccessNode is the host:port of the elected access node for this
+	// network, once SWIFT registration has completed.
+	AccessNode string `json:"accessNode,omitempty"`
+
+	// Conditions tracks the reconciliation state of the network, following
+	// the usual Kubernetes condition conventions.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Network",type=string,JSONPath=`.spec.network`
+// +kubebuilder:printcolumn:name="AccessNode",type=string,JSONPath=`.status.accessNode`
+
+// SwanNetwork is the Schema for the swannetworks API.
+type SwanNetwork struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SwanNetworkSpec   `json:"spec,omitempty"`
+	Status SwanNetworkStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SwanNetworkList contains a list of SwanNetwork.
+type SwanNetworkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SwanNetwork `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SwanNetwork{}, &SwanNetworkList{})
+}
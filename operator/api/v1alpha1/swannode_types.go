@@ -0,0 +1,98 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SwanNodeRole is the part a SwanNode plays in its SwanNetwork.
+type SwanNodeRole string
+
+const (
+	// SwanNodeRoleAccess runs the SWIFT storage operations for the
+	// network and is a candidate to be elected the access node.
+	SwanNodeRoleAccess SwanNodeRole = "access"
+
+	// SwanNodeRoleStorage only stores data for the network's access
+	// node; it is never elected.
+	SwanNodeRoleStorage SwanNodeRole = "storage"
+)
+
+// SwanNodeSpec describes a single SWAN pod: the host it will be reachable
+// on, the role it plays, the network it belongs to, and the key used to
+// sign the OWIDs it issues.
+type SwanNodeSpec struct {
+
+	// Host is the public host name the node will be reachable on, used
+	// both for SWIFT registration and as the domain registered with
+	// /owid/register.
+	Host string `json:"host"`
+
+	// Role is either "access" or "storage".
+	Role SwanNodeRole `json:"role"`
+
+	// NetworkRef names the SwanNetwork in the same namespace this node
+	// belongs to.
+	NetworkRef string `json:"networkRef"`
+
+	// SigningKeySecretRef names a Kubernetes Secret in the same namespace
+	// holding the OWID signing key registered on /owid/register.
+	SigningKeySecretRef corev1.LocalObjectReference `json:"signingKeySecretRef"`
+}
+
+// SwanNodeStatus reports how far the reconciler has got provisioning this
+// node.
+type SwanNodeStatus struct {
+
+	// Registered is true once OWID registration has completed successfully
+	// and, for an access node, the network has an elected access node.
+	Registered bool `json:"registered,omitempty"`
+
+	// Conditions tracks the reconciliation state of the node, following
+	// the usual Kubernetes condition conventions.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.role`
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="Registered",type=boolean,JSONPath=`.status.registered`
+
+// SwanNode is the Schema for the swannodes API.
+type SwanNode struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SwanNodeSpec   `json:"spec,omitempty"`
+	Status SwanNodeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SwanNodeList contains a list of SwanNode.
+type SwanNodeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SwanNode `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SwanNode{}, &SwanNodeList{})
+}
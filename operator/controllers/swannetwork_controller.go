@@ -0,0 +1,146 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	swanv1alpha1 "swan/operator/api/v1alpha1"
+)
+
+// SwanNetworkReconciler reconciles a SwanNetwork object: it elects an
+// access node from the network's SwanNodes and publishes it to
+// SwanNetworkStatus.AccessNode by calling /swift/register against it,
+// exactly as the message in createStorageOperationURL otherwise instructs
+// an operator to do by hand.
+type SwanNetworkReconciler struct {
+	client.Client
+}
+
+// +kubebuilder:rbac:groups=swan.51degrees.com,resources=swannetworks,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=swan.51degrees.com,resources=swannetworks/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=swan.51degrees.com,resources=swannodes,verbs=get;list;watch
+
+// Reconcile elects an access node for the network, if one hasn't been
+// elected yet, and registers it over SWIFT.
+func (r *SwanNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var network swanv1alpha1.SwanNetwork
+	if err := r.Get(ctx, req.NamespacedName, &network); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if network.Status.AccessNode != "" {
+		return ctrl.Result{}, nil
+	}
+
+	var nodes swanv1alpha1.SwanNodeList
+	if err := r.List(ctx, &nodes, client.InNamespace(req.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var candidate *swanv1alpha1.SwanNode
+	for i := range nodes.Items {
+		n := &nodes.Items[i]
+		if n.Spec.NetworkRef == network.Name && n.Spec.Role == swanv1alpha1.SwanNodeRoleAccess {
+			candidate = n
+			break
+		}
+	}
+	if candidate == nil {
+		// Nothing to elect yet; check again later, as SetupWithManager
+		// does not watch SwanNodes.
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	if err := r.registerSwift(ctx, &network, candidate.Spec.Host); err != nil {
+		return ctrl.Result{}, fmt.Errorf(
+			"SWIFT registration of access node '%s' failed: %w", candidate.Spec.Host, err)
+	}
+
+	network.Status.AccessNode = candidate.Spec.Host
+	if err := r.Status().Update(ctx, &network); err != nil {
+		return ctrl.Result{}, err
+	}
+	log.Info("elected SWAN access node", "network", network.Name, "accessNode", candidate.Spec.Host)
+
+	return ctrl.Result{}, nil
+}
+
+// registerSwift calls /swift/register on host to publish it as the
+// network's access node, mirroring the manual step createStorageOperationURL
+// currently tells an operator to perform themselves. The network's shared
+// access key, sourced from AccessSecretRef, is sent as a header rather than
+// a query parameter so it doesn't end up in proxy or access logs.
+func (r *SwanNetworkReconciler) registerSwift(
+	ctx context.Context,
+	network *swanv1alpha1.SwanNetwork,
+	host string) error {
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{
+		Namespace: network.Namespace,
+		Name:      network.Spec.AccessSecretRef,
+	}, &secret); err != nil {
+		return err
+	}
+	accessKey, ok := secret.Data["accessKey"]
+	if !ok {
+		return fmt.Errorf(
+			"secret '%s' has no 'accessKey' entry", network.Spec.AccessSecretRef)
+	}
+
+	u := url.URL{Scheme: network.Spec.Scheme, Host: host, Path: "/swift/register"}
+	q := u.Query()
+	q.Set("network", network.Spec.Network)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Swan-Access-Key", string(accessKey))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("'%s' returned '%d' and '%s'", u.String(), res.StatusCode, b)
+	}
+	return nil
+}
+
+// SetupWithManager wires the reconciler into mgr, watching SwanNetworks.
+// SwanNodes are not watched; Reconcile requeues a network that has no
+// access-role SwanNode yet.
+func (r *SwanNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&swanv1alpha1.SwanNetwork{}).
+		Complete(r)
+}
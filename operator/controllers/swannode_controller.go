@@ -0,0 +1,206 @@
+/* ****************************************************************************
+ * Copyright 2020 51 Degrees Mobile Experts Limited (51degrees.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ * ***************************************************************************/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	swanv1alpha1 "swan/operator/api/v1alpha1"
+)
+
+// serverImage is the container image built from this module's server
+// binary, used for every SwanNode's pod regardless of role.
+const serverImage = "51degrees/swan-go:latest"
+
+// SwanNodeReconciler reconciles a SwanNode object: it deploys the node's
+// pod and registers its OWID creator, mirroring what an operator would
+// otherwise do by hand against /owid/register. It makes no SWIFT call
+// itself; only the access node elected by SwanNetworkReconciler is
+// registered over /swift/register, and storage nodes are not.
+type SwanNodeReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=swan.51degrees.com,resources=swannodes,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=swan.51degrees.com,resources=swannodes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=swan.51degrees.com,resources=swannetworks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile brings the SWAN pod for a SwanNode in line with its spec, then
+// registers it with its network once the pod is deployed.
+func (r *SwanNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	var node swanv1alpha1.SwanNode
+	if err := r.Get(ctx, req.NamespacedName, &node); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var network swanv1alpha1.SwanNetwork
+	if err := r.Get(ctx, types.NamespacedName{
+		Namespace: req.Namespace,
+		Name:      node.Spec.NetworkRef,
+	}, &network); err != nil {
+		return ctrl.Result{}, fmt.Errorf(
+			"SwanNetwork '%s' referenced by SwanNode '%s' not found: %w",
+			node.Spec.NetworkRef, node.Name, err)
+	}
+
+	if err := r.reconcileDeployment(ctx, &node, &network); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if node.Status.Registered {
+		return ctrl.Result{}, nil
+	}
+
+	if node.Spec.Role == swanv1alpha1.SwanNodeRoleAccess && network.Status.AccessNode == "" {
+		// The network doesn't have an elected access node yet; the
+		// SwanNetwork controller elects one and registers it with SWIFT.
+		// SwanNetworks are not watched here, so check again later.
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	}
+
+	if err := r.registerOWID(ctx, &node, &network); err != nil {
+		return ctrl.Result{}, fmt.Errorf("OWID registration failed: %w", err)
+	}
+
+	node.Status.Registered = true
+	if err := r.Status().Update(ctx, &node); err != nil {
+		return ctrl.Result{}, err
+	}
+	log.Info("registered SWAN node", "host", node.Spec.Host, "role", node.Spec.Role)
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileDeployment creates or updates the single-replica Deployment that
+// runs this SwanNode's SWAN pod. The network's access node is passed to
+// the swan container in the SWAN_ACCESS_NODE environment variable, which is
+// empty until the network has an elected access node. The Deployment has no
+// init container.
+func (r *SwanNodeReconciler) reconcileDeployment(
+	ctx context.Context,
+	node *swanv1alpha1.SwanNode,
+	network *swanv1alpha1.SwanNetwork) error {
+
+	dep := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: node.Name, Namespace: node.Namespace},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, dep, func() error {
+		replicas := int32(1)
+		dep.Spec.Replicas = &replicas
+		dep.Spec.Selector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"swan.51degrees.com/node": node.Name},
+		}
+		dep.Spec.Template.ObjectMeta.Labels = dep.Spec.Selector.MatchLabels
+		dep.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name:  "swan",
+			Image: serverImage,
+			Env: []corev1.EnvVar{
+				{Name: "SWAN_NETWORK", Value: network.Spec.Network},
+				{Name: "SWAN_SCHEME", Value: network.Spec.Scheme},
+				{Name: "SWAN_ACCESS_NODE", Value: network.Status.AccessNode},
+				{Name: "SWAN_ACCESS_KEY", ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{
+							Name: network.Spec.AccessSecretRef,
+						},
+						Key: "accessKey",
+					},
+				}},
+			},
+		}}
+		return controllerutil.SetControllerReference(node, dep, r.Scheme)
+	})
+	return err
+}
+
+// registerOWID calls /owid/register on the node's own host, using the
+// signing key sourced from SigningKeySecretRef, exactly as an operator
+// would otherwise be instructed to do by the error message in
+// encodeAsOWID. The key travels in the POST body rather than the URL so it
+// doesn't end up in proxy or access logs, and the scheme matches the
+// network's, the same as every other call this operator makes.
+func (r *SwanNodeReconciler) registerOWID(
+	ctx context.Context,
+	node *swanv1alpha1.SwanNode,
+	network *swanv1alpha1.SwanNetwork) error {
+	var secret corev1.Secret
+	err := r.Get(ctx, types.NamespacedName{
+		Namespace: node.Namespace,
+		Name:      node.Spec.SigningKeySecretRef.Name,
+	}, &secret)
+	if err != nil {
+		return err
+	}
+	key, ok := secret.Data["signingKey"]
+	if !ok {
+		return fmt.Errorf(
+			"secret '%s' has no 'signingKey' entry", node.Spec.SigningKeySecretRef.Name)
+	}
+
+	body, err := json.Marshal(struct {
+		Domain string `json:"domain"`
+		Key    string `json:"key"`
+	}{Domain: node.Spec.Host, Key: string(key)})
+	if err != nil {
+		return err
+	}
+
+	u := url.URL{Scheme: network.Spec.Scheme, Host: node.Spec.Host, Path: "/owid/register"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("OWID registration for '%s' returned '%d'", node.Spec.Host, res.StatusCode)
+	}
+	return nil
+}
+
+// SetupWithManager wires the reconciler into mgr, watching SwanNodes and
+// the Deployments it owns.
+func (r *SwanNodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&swanv1alpha1.SwanNode{}).
+		Owns(&appsv1.Deployment{}).
+		Complete(r)
+}